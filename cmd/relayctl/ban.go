@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rumblefrog/source-chat-relay/server/entity"
+	"github.com/urfave/cli/v2"
+)
+
+// banCommand exposes the same ban/unban operations available through
+// the /relay ban slash command, for operators who'd rather script it or
+// don't have a Discord admin role handy.
+var banCommand = &cli.Command{
+	Name:  "ban",
+	Usage: "Manage the relay ban list",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Ban a token, IP, or player name",
+			ArgsUsage: "<kind> <value> [reason]",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:  "duration",
+					Usage: "how long the ban lasts (e.g. 1h, 30m); omit for a permanent ban",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.Args().Len() < 2 {
+					return cli.Exit("usage: relayctl ban add <kind> <value> [reason]", 1)
+				}
+
+				reason := c.Args().Get(2)
+
+				if err := entity.AddBan(c.Args().Get(0), c.Args().Get(1), reason, "relayctl", c.Duration("duration")); err != nil {
+					return cli.Exit(err, 1)
+				}
+
+				if d := c.Duration("duration"); d > 0 {
+					fmt.Printf("Banned %s %q for %s\n", c.Args().Get(0), c.Args().Get(1), d)
+				} else {
+					fmt.Printf("Banned %s %q permanently\n", c.Args().Get(0), c.Args().Get(1))
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:      "remove",
+			Usage:     "Remove a ban",
+			ArgsUsage: "<kind> <value>",
+			Action: func(c *cli.Context) error {
+				if c.Args().Len() < 2 {
+					return cli.Exit("usage: relayctl ban remove <kind> <value>", 1)
+				}
+
+				if err := entity.RemoveBan(c.Args().Get(0), c.Args().Get(1)); err != nil {
+					return cli.Exit(err, 1)
+				}
+
+				fmt.Printf("Unbanned %s %q\n", c.Args().Get(0), c.Args().Get(1))
+
+				return nil
+			},
+		},
+	},
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// relayctl is a small administrative CLI for a running relay instance,
+// for operators scripting it or without a Discord admin role handy.
+// Today it only wraps the ban list, which it edits directly through the
+// entity package rather than over the control socket; the in-memory ban
+// cache that a separately running relay process checks is not refreshed
+// by these edits.
+func main() {
+	app := &cli.App{
+		Name:  "relayctl",
+		Usage: "Administer a running source-chat-relay instance",
+		Commands: []*cli.Command{
+			banCommand,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		logrus.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package entity
+
+import (
+	"sync"
+	"time"
+)
+
+// Ban kinds. A source can be banned by any identity it's known under —
+// its relay token, the hostname/IP the TCP connection came from, or the
+// in-game player name/steamid embedded in a ChatMessage.
+const (
+	BanKindToken = "token"
+	BanKindIP    = "ip"
+	BanKindName  = "name"
+)
+
+// Ban is a single row of the bans table.
+type Ban struct {
+	Kind      string
+	Value     string
+	ExpiresAt time.Time
+	Reason    string
+	AddedBy   string
+}
+
+func (b Ban) expired() bool {
+	return !b.ExpiresAt.IsZero() && time.Now().After(b.ExpiresAt)
+}
+
+// banCache mirrors the bans table in memory so StartRouting and
+// AuthenticateClient can check it on every message without hitting the
+// database. It's refreshed on every write rather than polled.
+var banCache = struct {
+	sync.RWMutex
+	entries map[string]map[string]Ban // kind -> value -> Ban
+}{
+	entries: make(map[string]map[string]Ban),
+}
+
+// LoadBans populates the cache from the database. Call once at startup
+// before the relay starts accepting connections.
+func LoadBans() error {
+	rows, err := db.Query("SELECT kind, value, expires_at, reason, added_by FROM bans")
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	banCache.Lock()
+	defer banCache.Unlock()
+
+	banCache.entries = make(map[string]map[string]Ban)
+
+	for rows.Next() {
+		var b Ban
+
+		if err := rows.Scan(&b.Kind, &b.Value, &b.ExpiresAt, &b.Reason, &b.AddedBy); err != nil {
+			return err
+		}
+
+		cacheBanLocked(b)
+	}
+
+	return rows.Err()
+}
+
+func cacheBanLocked(b Ban) {
+	if banCache.entries[b.Kind] == nil {
+		banCache.entries[b.Kind] = make(map[string]Ban)
+	}
+
+	banCache.entries[b.Kind][b.Value] = b
+}
+
+// AddBan inserts a ban and immediately refreshes the cache so it takes
+// effect on the very next message, without waiting for a reload. A
+// zero duration bans permanently; otherwise ExpiresAt is set to
+// duration from now, and expired()/IsBanned stop enforcing it once
+// that passes.
+func AddBan(kind, value, reason, addedBy string, duration time.Duration) error {
+	b := Ban{
+		Kind:    kind,
+		Value:   value,
+		Reason:  reason,
+		AddedBy: addedBy,
+	}
+
+	if duration > 0 {
+		b.ExpiresAt = time.Now().Add(duration)
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO bans (kind, value, expires_at, reason, added_by) VALUES (?, ?, ?, ?, ?)",
+		b.Kind, b.Value, b.ExpiresAt, b.Reason, b.AddedBy,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	banCache.Lock()
+	cacheBanLocked(b)
+	banCache.Unlock()
+
+	return nil
+}
+
+// RemoveBan deletes a ban and evicts it from the cache.
+func RemoveBan(kind, value string) error {
+	if _, err := db.Exec("DELETE FROM bans WHERE kind = ? AND value = ?", kind, value); err != nil {
+		return err
+	}
+
+	banCache.Lock()
+	delete(banCache.entries[kind], value)
+	banCache.Unlock()
+
+	return nil
+}
+
+// IsBanned reports whether the given identity is currently banned,
+// treating an expired ban as absent.
+func IsBanned(kind, value string) bool {
+	banCache.RLock()
+	b, ok := banCache.entries[kind][value]
+	banCache.RUnlock()
+
+	return ok && !b.expired()
+}
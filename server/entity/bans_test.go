@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+// resetBanCache clears the package-level cache so tests don't leak
+// entries into each other; it bypasses the database entirely since
+// these tests only exercise the in-memory cache logic.
+func resetBanCache() {
+	banCache.Lock()
+	defer banCache.Unlock()
+
+	banCache.entries = make(map[string]map[string]Ban)
+}
+
+func TestIsBannedUnknownValue(t *testing.T) {
+	resetBanCache()
+
+	if IsBanned(BanKindToken, "nope") {
+		t.Fatal("IsBanned should be false for a value never banned")
+	}
+}
+
+func TestIsBannedActiveBan(t *testing.T) {
+	resetBanCache()
+
+	banCache.Lock()
+	cacheBanLocked(Ban{Kind: BanKindToken, Value: "abc123", Reason: "cheating"})
+	banCache.Unlock()
+
+	if !IsBanned(BanKindToken, "abc123") {
+		t.Fatal("IsBanned should be true for a cached, non-expired ban")
+	}
+}
+
+func TestIsBannedExpiredBanIsIgnored(t *testing.T) {
+	resetBanCache()
+
+	banCache.Lock()
+	cacheBanLocked(Ban{
+		Kind:      BanKindName,
+		Value:     "griefer",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	banCache.Unlock()
+
+	if IsBanned(BanKindName, "griefer") {
+		t.Fatal("IsBanned should treat an expired ban as absent")
+	}
+}
+
+func TestIsBannedDifferentKindDoesNotMatch(t *testing.T) {
+	resetBanCache()
+
+	banCache.Lock()
+	cacheBanLocked(Ban{Kind: BanKindIP, Value: "1.2.3.4"})
+	banCache.Unlock()
+
+	if IsBanned(BanKindToken, "1.2.3.4") {
+		t.Fatal("a ban on one kind should not match a lookup on another kind")
+	}
+}
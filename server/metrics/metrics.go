@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Per-destination counters/gauges, labeled by entity ID ("bot" for the
+// Discord side), so operators running many game servers can see which
+// one is falling behind.
+var (
+	MessagesRouted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_messages_routed_total",
+		Help: "Number of messages successfully queued for delivery, by destination.",
+	}, []string{"destination"})
+
+	MessagesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "relay_messages_dropped_total",
+		Help: "Number of messages dropped because the destination queue was full.",
+	}, []string{"destination"})
+
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "relay_queue_depth",
+		Help: "Current number of messages buffered for a destination.",
+	}, []string{"destination"})
+
+	LastDeliveryLatencySeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "relay_last_delivery_latency_seconds",
+		Help: "Time between a message being queued and handed off to the destination.",
+	}, []string{"destination"})
+)
+
+// Serve starts a small HTTP server exposing the Prometheus registry at
+// /metrics. It blocks until the listener errors, so callers should run
+// it in its own goroutine.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return http.ListenAndServe(addr, mux)
+}
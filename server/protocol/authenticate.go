@@ -0,0 +1,80 @@
+package protocol
+
+import "github.com/rumblefrog/source-chat-relay/server/packet"
+
+// MessageType identifies the kind of payload a BaseMessage wraps, so
+// HandlePacket can dispatch on it before decoding the type-specific
+// payload.
+type MessageType byte
+
+const (
+	MessageAuthenticate MessageType = iota
+	MessageChat
+	MessageEvent
+)
+
+// BaseMessage is the envelope every wire message shares: just enough to
+// dispatch and, once authenticated, stamp with the sending client's
+// identity before the type-specific payload is decoded.
+type BaseMessage struct {
+	Type     MessageType
+	SenderID string
+	Hostname string
+}
+
+// ParseBaseMessage reads the envelope off the front of reader, leaving
+// the type-specific payload for the matching ParseXMessage call.
+func ParseBaseMessage(reader *packet.PacketReader) *BaseMessage {
+	return &BaseMessage{
+		Type: MessageType(reader.ReadByte()),
+	}
+}
+
+// AuthenticateMessage is the client's handshake payload: the entity
+// token and hostname identifying it, plus the wire protocol version it
+// speaks so the server can negotiate down for older SourceMod plugins
+// via NegotiateVersion.
+type AuthenticateMessage struct {
+	*BaseMessage
+
+	Token    []byte
+	Hostname string
+
+	ProtocolVersion int
+}
+
+// ParseAuthenticateMessage decodes the Authenticate payload following
+// base off reader.
+func ParseAuthenticateMessage(base *BaseMessage, reader *packet.PacketReader) *AuthenticateMessage {
+	return &AuthenticateMessage{
+		BaseMessage:     base,
+		Token:           reader.ReadBytes(),
+		Hostname:        reader.ReadString(),
+		ProtocolVersion: int(reader.ReadByte()),
+	}
+}
+
+// AuthenticateResponse is the accept/deny outcome the server reports
+// back to a connecting client.
+type AuthenticateResponse byte
+
+const (
+	AuthenticateSuccess AuthenticateResponse = iota
+	AuthenticateDenied
+)
+
+// AuthenticateMessageResponse is the server's reply to
+// AuthenticateMessage. ProtocolVersion carries the version
+// NegotiateVersion picked for the session, so the client knows which
+// wire format to speak for every packet after this one.
+type AuthenticateMessageResponse struct {
+	Response AuthenticateResponse
+
+	ProtocolVersion int
+}
+
+// Marshal encodes the response for writing directly to the client
+// socket.
+func (m *AuthenticateMessageResponse) Marshal() []byte {
+	return []byte{byte(m.Response), byte(m.ProtocolVersion)}
+}
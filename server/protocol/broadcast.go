@@ -0,0 +1,59 @@
+package protocol
+
+import "encoding/binary"
+
+// Deliverable is satisfied by every message type that can be queued
+// onto Relay.Router: routing needs the author identity to apply bans
+// and skip echoing a message back to its own sender, and the marshaled
+// bytes to hand off to a client socket or the Discord bot.
+type Deliverable interface {
+	Content() string
+	Author() string
+	Marshal() []byte
+}
+
+// MessageBroadcast is the wire type for an operator-originated
+// broadcast queued via the control socket.
+const MessageBroadcast MessageType = 255
+
+// controlAuthor is the Author() a BroadcastMessage reports. It never
+// matches a real entity token, so the usual ban check and the
+// "don't echo back to the sender" comparison both treat it as an
+// unbanned, unrecognized source.
+const controlAuthor = "control"
+
+// BroadcastMessage carries operator text submitted via the control
+// socket's "broadcast" command. It satisfies Deliverable so it can be
+// queued directly onto Relay.Router and delivered to clients and the
+// Discord bot through the same path as any other message, rather than
+// being written to clients as a raw, unframed string.
+type BroadcastMessage struct {
+	Text string
+}
+
+// NewBroadcastMessage wraps operator text from the control socket as a
+// Deliverable ready to queue onto Relay.Router.
+func NewBroadcastMessage(text string) *BroadcastMessage {
+	return &BroadcastMessage{Text: text}
+}
+
+func (m *BroadcastMessage) Content() string {
+	return m.Text
+}
+
+func (m *BroadcastMessage) Author() string {
+	return controlAuthor
+}
+
+// Marshal frames the broadcast as a 4-byte big-endian length prefix
+// followed by a type byte and the payload. (AuthenticateMessageResponse
+// is the exception among outbound messages: it is written unframed.)
+func (m *BroadcastMessage) Marshal() []byte {
+	payload := append([]byte{byte(MessageBroadcast)}, []byte(m.Text)...)
+
+	framed := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(framed, uint32(len(payload)))
+	copy(framed[4:], payload)
+
+	return framed
+}
@@ -0,0 +1,14 @@
+package protocol
+
+// ChatMessage carries the in-game player's name and SteamID alongside
+// the chat text (set by ParseChatMessage off the SourceMod plugin's
+// payload). These satisfy relay.namedIdentity so StartRouting can
+// enforce name/steamid bans the same way it enforces token bans,
+// without needing to know about ChatMessage specifically.
+func (m *ChatMessage) PlayerName() string {
+	return m.Name
+}
+
+func (m *ChatMessage) PlayerSteamID() string {
+	return m.SteamID
+}
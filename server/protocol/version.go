@@ -0,0 +1,24 @@
+package protocol
+
+// CurrentProtocolVersion is the newest wire protocol this server speaks.
+// Bumping it lets new packet types (attachments, presence, ack) be
+// added without breaking older SourceMod plugins, which negotiate down
+// to whatever version they advertised in AuthenticateMessage.
+const CurrentProtocolVersion = 2
+
+// NegotiateVersion picks the protocol version the server should use for
+// a connection, given the version the client advertised. Older client
+// versions are honored as-is and an unset (zero or negative) version is
+// treated as v1, so legacy plugins keep working; anything newer than we
+// speak is capped at our current version.
+func NegotiateVersion(clientVersion int) int {
+	if clientVersion <= 0 {
+		return 1
+	}
+
+	if clientVersion > CurrentProtocolVersion {
+		return CurrentProtocolVersion
+	}
+
+	return clientVersion
+}
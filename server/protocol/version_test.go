@@ -0,0 +1,25 @@
+package protocol
+
+import "testing"
+
+func TestNegotiateVersion(t *testing.T) {
+	cases := []struct {
+		name   string
+		client int
+		want   int
+	}{
+		{"unset defaults to v1", 0, 1},
+		{"negative defaults to v1", -1, 1},
+		{"older client version is honored", 1, 1},
+		{"current version is honored", CurrentProtocolVersion, CurrentProtocolVersion},
+		{"newer than current is capped", CurrentProtocolVersion + 5, CurrentProtocolVersion},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NegotiateVersion(tc.client); got != tc.want {
+				t.Errorf("NegotiateVersion(%d) = %d, want %d", tc.client, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/rumblefrog/source-chat-relay/server/filter"
+	"github.com/rumblefrog/source-chat-relay/server/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// ControlRequest is a single command sent down the control socket.
+type ControlRequest struct {
+	Cmd   string          `json:"cmd"`
+	Args  json.RawMessage `json:"args"`
+	Nonce string          `json:"nonce"`
+}
+
+// ControlResponse answers a ControlRequest, echoing its nonce so
+// pipelined requests on the same connection can be matched up.
+type ControlResponse struct {
+	Nonce string      `json:"nonce"`
+	OK    bool        `json:"ok"`
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// ControlEvent is pushed to subscribers of the "tail-events" command.
+type ControlEvent struct {
+	Kind string      `json:"kind"`
+	Data interface{} `json:"data"`
+}
+
+// controlEvents fans out routing activity to any open tail-events subscribers,
+// mirroring the bounded, non-blocking send pattern used for clients so a
+// slow tail subscriber can't stall the relay.
+var controlEvents = struct {
+	sync.Mutex
+	subscribers map[chan ControlEvent]bool
+}{
+	subscribers: make(map[chan ControlEvent]bool),
+}
+
+func publishControlEvent(ev ControlEvent) {
+	controlEvents.Lock()
+	defer controlEvents.Unlock()
+
+	for ch := range controlEvents.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// ListenControl opens the control socket at path (a Unix domain socket
+// on Linux/macOS, a named pipe on Windows — see control_unix.go and
+// control_windows.go) and serves the JSON command protocol on it. This
+// mirrors Discord's own local RPC socket: an operator or sidecar
+// process can manage the running relay without restarting it or
+// exposing another TCP port.
+func (r *Relay) ListenControl(path string) error {
+	listener, err := controlListen(path)
+
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				logrus.WithField("error", err).Warn("Control socket accept failed")
+
+				return
+			}
+
+			go r.handleControlConn(conn)
+		}
+	}()
+
+	return nil
+}
+
+func (r *Relay) handleControlConn(conn controlConn) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		var req ControlRequest
+
+		if err := decoder.Decode(&req); err != nil {
+			return
+		}
+
+		if req.Cmd == "tail-events" {
+			r.streamControlEvents(conn, encoder, req.Nonce)
+
+			return
+		}
+
+		resp := r.dispatchControl(req)
+
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (r *Relay) streamControlEvents(conn controlConn, encoder *json.Encoder, nonce string) {
+	ch := make(chan ControlEvent, 64)
+
+	controlEvents.Lock()
+	controlEvents.subscribers[ch] = true
+	controlEvents.Unlock()
+
+	defer func() {
+		controlEvents.Lock()
+		delete(controlEvents.subscribers, ch)
+		controlEvents.Unlock()
+	}()
+
+	encoder.Encode(ControlResponse{Nonce: nonce, OK: true, Data: "subscribed"})
+
+	for ev := range ch {
+		if err := encoder.Encode(ev); err != nil {
+			return
+		}
+	}
+}
+
+func (r *Relay) dispatchControl(req ControlRequest) ControlResponse {
+	switch req.Cmd {
+	case "list-clients":
+		return r.controlListClients(req.Nonce)
+	case "kick":
+		return r.controlKick(req)
+	case "broadcast":
+		return r.controlBroadcast(req)
+	case "reload-config":
+		return r.controlReloadConfig(req)
+	case "reload-filters":
+		return r.controlReloadFilters(req)
+	case "stats":
+		return r.controlStats(req.Nonce)
+	default:
+		return ControlResponse{Nonce: req.Nonce, OK: false, Error: fmt.Sprintf("unknown command %q", req.Cmd)}
+	}
+}
+
+func (r *Relay) controlListClients(nonce string) ControlResponse {
+	r.clientsMu.RLock()
+	defer r.clientsMu.RUnlock()
+
+	clients := make([]map[string]string, 0, len(r.Clients))
+
+	for c := range r.Clients {
+		clients = append(clients, map[string]string{"id": c.ID, "hostname": c.Hostname})
+	}
+
+	return ControlResponse{Nonce: nonce, OK: true, Data: clients}
+}
+
+func (r *Relay) controlKick(req ControlRequest) ControlResponse {
+	var args struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.Unmarshal(req.Args, &args); err != nil {
+		return ControlResponse{Nonce: req.Nonce, OK: false, Error: err.Error()}
+	}
+
+	// Find and remove under a single write lock rather than calling
+	// RemoveClient (which also locks) to avoid re-entering clientsMu.
+	r.clientsMu.Lock()
+
+	var target *RelayClient
+
+	for c := range r.Clients {
+		if c.ID == args.ID {
+			target = c
+
+			break
+		}
+	}
+
+	if target != nil {
+		close(target.Data)
+		delete(r.Clients, target)
+	}
+
+	r.clientsMu.Unlock()
+
+	if target == nil {
+		return ControlResponse{Nonce: req.Nonce, OK: false, Error: "no such client"}
+	}
+
+	target.Socket.Close()
+
+	return ControlResponse{Nonce: req.Nonce, OK: true}
+}
+
+// controlBroadcast queues the operator's text onto Router as a
+// protocol.BroadcastMessage, so it reaches connected clients and the
+// Discord bot through the same path as any other message instead of
+// being written to clients as a raw, unframed string.
+func (r *Relay) controlBroadcast(req ControlRequest) ControlResponse {
+	var args struct {
+		Text string `json:"text"`
+	}
+
+	if err := json.Unmarshal(req.Args, &args); err != nil {
+		return ControlResponse{Nonce: req.Nonce, OK: false, Error: err.Error()}
+	}
+
+	select {
+	case r.Router <- protocol.NewBroadcastMessage(args.Text):
+	default:
+		return ControlResponse{Nonce: req.Nonce, OK: false, Error: "router busy, broadcast dropped"}
+	}
+
+	publishControlEvent(ControlEvent{Kind: "broadcast", Data: args.Text})
+
+	return ControlResponse{Nonce: req.Nonce, OK: true}
+}
+
+func (r *Relay) controlReloadConfig(req ControlRequest) ControlResponse {
+	return ControlResponse{Nonce: req.Nonce, OK: false, Error: "reload-config is not implemented yet"}
+}
+
+func (r *Relay) controlReloadFilters(req ControlRequest) ControlResponse {
+	if err := filter.Reload(); err != nil {
+		return ControlResponse{Nonce: req.Nonce, OK: false, Error: err.Error()}
+	}
+
+	publishControlEvent(ControlEvent{Kind: "reload-filters"})
+
+	return ControlResponse{Nonce: req.Nonce, OK: true}
+}
+
+func (r *Relay) controlStats(nonce string) ControlResponse {
+	r.clientsMu.RLock()
+	clientCount := len(r.Clients)
+	r.clientsMu.RUnlock()
+
+	return ControlResponse{
+		Nonce: nonce,
+		OK:    true,
+		Data: map[string]int{
+			"clients":    clientCount,
+			"bot_queued": len(r.Bot),
+		},
+	}
+}
+
+// controlConn is the minimal surface handleControlConn needs from a
+// socket/pipe connection, satisfied by both net.Conn and the winio pipe
+// connection type.
+type controlConn interface {
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+	Close() error
+}
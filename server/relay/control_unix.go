@@ -0,0 +1,18 @@
+//go:build !windows
+
+package relay
+
+import (
+	"net"
+	"os"
+)
+
+// controlListen opens the control socket as a Unix domain socket,
+// removing any stale socket file left behind by a previous run.
+func controlListen(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package relay
+
+import (
+	"net"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// controlListen opens the control socket as a named pipe, since Windows
+// has no Unix domain sockets on the versions this relay still needs to
+// support.
+func controlListen(path string) (net.Listener, error) {
+	return winio.ListenPipe(path, nil)
+}
@@ -0,0 +1,123 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// chunkedReader dribbles out the underlying bytes a few at a time, to
+// simulate a TCP read returning a partial frame.
+type chunkedReader struct {
+	data      []byte
+	chunkSize int
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(c.data) == 0 {
+		return 0, io.EOF
+	}
+
+	n := c.chunkSize
+
+	if n > len(c.data) {
+		n = len(c.data)
+	}
+
+	if n > len(p) {
+		n = len(p)
+	}
+
+	copy(p, c.data[:n])
+	c.data = c.data[n:]
+
+	return n, nil
+}
+
+func frame(payload []byte) []byte {
+	buf := make([]byte, frameLengthPrefixSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[frameLengthPrefixSize:], payload)
+
+	return buf
+}
+
+func TestReadFrameWholeFrame(t *testing.T) {
+	payload := []byte("hello world")
+	r := bytes.NewReader(frame(payload))
+
+	got, err := readFrame(r)
+
+	if err != nil {
+		t.Fatalf("readFrame returned error: %v", err)
+	}
+
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("readFrame = %q, want %q", got, payload)
+	}
+}
+
+func TestReadFramePartialReads(t *testing.T) {
+	payload := []byte("a slightly longer payload to split across reads")
+	r := &chunkedReader{data: frame(payload), chunkSize: 3}
+
+	got, err := readFrame(r)
+
+	if err != nil {
+		t.Fatalf("readFrame returned error: %v", err)
+	}
+
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("readFrame = %q, want %q", got, payload)
+	}
+}
+
+func TestReadFrameCoalescedFrames(t *testing.T) {
+	first := []byte("first")
+	second := []byte("second-message")
+
+	buf := append(frame(first), frame(second)...)
+	r := bytes.NewReader(buf)
+
+	got, err := readFrame(r)
+
+	if err != nil {
+		t.Fatalf("readFrame (first) returned error: %v", err)
+	}
+
+	if !bytes.Equal(got, first) {
+		t.Fatalf("first frame = %q, want %q", got, first)
+	}
+
+	got, err = readFrame(r)
+
+	if err != nil {
+		t.Fatalf("readFrame (second) returned error: %v", err)
+	}
+
+	if !bytes.Equal(got, second) {
+		t.Fatalf("second frame = %q, want %q", got, second)
+	}
+}
+
+func TestReadFrameRejectsOversizedLength(t *testing.T) {
+	lengthPrefix := make([]byte, frameLengthPrefixSize)
+	binary.BigEndian.PutUint32(lengthPrefix, maxFrameLength+1)
+
+	_, err := readFrame(bytes.NewReader(lengthPrefix))
+
+	if err == nil {
+		t.Fatal("expected an error for an oversized frame length, got nil")
+	}
+}
+
+func TestReadFrameRejectsTruncatedPayload(t *testing.T) {
+	full := frame([]byte("truncated"))
+
+	_, err := readFrame(bytes.NewReader(full[:len(full)-2]))
+
+	if err == nil {
+		t.Fatal("expected an error for a truncated payload, got nil")
+	}
+}
@@ -1,12 +1,18 @@
 package relay
 
 import (
+	"bufio"
 	"database/sql"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/rumblefrog/source-chat-relay/server/entity"
 	"github.com/rumblefrog/source-chat-relay/server/filter"
+	"github.com/rumblefrog/source-chat-relay/server/metrics"
 	"github.com/rumblefrog/source-chat-relay/server/packet"
 
 	"github.com/rumblefrog/source-chat-relay/server/protocol"
@@ -15,16 +21,48 @@ import (
 
 var Instance *Relay
 
+// clientQueueSize and botQueueSize bound each destination's outbound
+// ring buffer. A single stalled TCP client or a slow Discord bot can
+// then only ever block itself, not the router or other destinations.
+const (
+	clientQueueSize = 256
+	botQueueSize    = 256
+)
+
+// Frame format: a 4-byte big-endian length prefix followed by exactly
+// that many bytes of payload. maxFrameLength guards against a
+// misbehaving or malicious client claiming an enormous frame and
+// exhausting memory before we've even parsed it.
+const (
+	frameLengthPrefixSize = 4
+	maxFrameLength        = 1 << 20 // 1 MiB
+	clientReadTimeout     = 60 * time.Second
+)
+
 type Relay struct {
-	Clients  map[*RelayClient]bool
-	Router   chan protocol.Deliverable
-	Bot      chan protocol.Deliverable
-	Listener net.Listener
+	Clients   map[*RelayClient]bool
+	clientsMu sync.RWMutex
+	Router    chan protocol.Deliverable
+	Bot       chan BotQueueItem
+	Listener  net.Listener
+}
+
+// BotQueueItem wraps a message with the time it was queued so the bot
+// worker can report delivery latency once it dequeues it.
+type BotQueueItem struct {
+	Message protocol.Deliverable
+	Queued  time.Time
+}
+
+// ClientQueueItem is the RelayClient equivalent of BotQueueItem.
+type ClientQueueItem struct {
+	Payload []byte
+	Queued  time.Time
 }
 
 type RelayClient struct {
 	Socket   net.Conn
-	Data     chan []byte
+	Data     chan ClientQueueItem
 	ID       string
 	Hostname string
 }
@@ -37,19 +75,36 @@ func NewRelay() *Relay {
 	return &Relay{
 		Clients: make(map[*RelayClient]bool),
 		Router:  make(chan protocol.Deliverable),
-		Bot:     make(chan protocol.Deliverable),
+		Bot:     make(chan BotQueueItem, botQueueSize),
 	}
 }
 
-func (r *Relay) Listen(port int) error {
+// Listen opens the relay's TCP listener and, when controlSocketPath is
+// non-empty, the local control socket alongside it. The control socket
+// gives an operator or sidecar process a way to manage the running
+// relay (list/kick clients, broadcast, reload filters) without exposing
+// another TCP port. Passing a non-nil tlsOpts upgrades the TCP listener
+// to TLS, optionally requiring client certificates. When metricsAddr is
+// non-empty, the Prometheus /metrics endpoint is also served there.
+func (r *Relay) Listen(port int, controlSocketPath string, tlsOpts *TLSOptions, metricsAddr string) error {
 	var err error
 
-	r.Listener, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+	r.Listener, err = listen(fmt.Sprintf(":%d", port), tlsOpts)
 
 	if err != nil {
 		return err
 	}
 
+	if len(controlSocketPath) != 0 {
+		if err := r.ListenControl(controlSocketPath); err != nil {
+			return err
+		}
+	}
+
+	if len(metricsAddr) != 0 {
+		r.ServeMetrics(metricsAddr)
+	}
+
 	go r.StartRouting()
 	go r.ProcessConnections()
 
@@ -61,10 +116,21 @@ func (r *Relay) StartRouting() {
 		select {
 		case message := <-r.Router:
 			if filter.IsInFilter(message.Content()) {
-				return
+				continue
 			}
 
-			// Iterate connected clients
+			if entity.IsBanned(entity.BanKindToken, message.Author()) || isIdentityBanned(message) {
+				logrus.WithField("author", message.Author()).Warn("Dropping message from banned source")
+
+				continue
+			}
+
+			// Iterate connected clients. Held for the whole pass since an
+			// overflowing queue deletes from the map mid-iteration, and
+			// that delete must stay serialized against AddClient/RemoveClient
+			// and the control socket's list-clients/kick/stats commands.
+			r.clientsMu.Lock()
+
 			for client := range r.Clients {
 				tEntity, err := entity.GetEntity(client.ID)
 
@@ -75,20 +141,61 @@ func (r *Relay) StartRouting() {
 				if client.ID != message.Author() &&
 					tEntity.ReceiveIntersectsWith(entity.DeliverableSendChannels(message)) {
 					select {
-					case client.Data <- message.Marshal():
+					case client.Data <- ClientQueueItem{Payload: message.Marshal(), Queued: time.Now()}:
+						metrics.MessagesRouted.WithLabelValues(client.ID).Inc()
 					default:
+						metrics.MessagesDropped.WithLabelValues(client.ID).Inc()
+
+						logrus.WithField("client", client.ID).Warn("Client queue full, dropping message")
+
 						close(client.Data)
 						delete(r.Clients, client)
 					}
+
+					metrics.QueueDepth.WithLabelValues(client.ID).Set(float64(len(client.Data)))
 				}
 			}
 
-			// Push to bot channel and it'll iterate Discord channels
-			r.Bot <- message
+			r.clientsMu.Unlock()
+
+			// Push to the bot's queue without blocking the router on a
+			// slow or disconnected Discord session.
+			select {
+			case r.Bot <- BotQueueItem{Message: message, Queued: time.Now()}:
+				metrics.MessagesRouted.WithLabelValues("bot").Inc()
+			default:
+				metrics.MessagesDropped.WithLabelValues("bot").Inc()
+
+				logrus.Warn("Bot queue full, dropping message")
+			}
+
+			metrics.QueueDepth.WithLabelValues("bot").Set(float64(len(r.Bot)))
+
+			publishControlEvent(ControlEvent{Kind: "message-routed", Data: message.Author()})
 		}
 	}
 }
 
+// namedIdentity is implemented by protocol messages that carry an
+// in-game player identity (e.g. ChatMessage), so bans by name/steamid
+// can be enforced without StartRouting knowing about concrete message
+// types.
+type namedIdentity interface {
+	PlayerName() string
+	PlayerSteamID() string
+}
+
+func isIdentityBanned(message protocol.Deliverable) bool {
+	named, ok := message.(namedIdentity)
+
+	if !ok {
+		return false
+	}
+
+	return entity.IsBanned(entity.BanKindName, named.PlayerName()) ||
+		entity.IsBanned(entity.BanKindName, named.PlayerSteamID())
+}
+
 func (r *Relay) ProcessConnections() {
 	for {
 		conn, err := r.Listener.Accept()
@@ -102,7 +209,7 @@ func (r *Relay) ProcessConnections() {
 
 		client := &RelayClient{
 			Socket: conn,
-			Data:   make(chan []byte),
+			Data:   make(chan ClientQueueItem, clientQueueSize),
 		}
 
 		r.AddClient(client)
@@ -112,11 +219,44 @@ func (r *Relay) ProcessConnections() {
 	}
 }
 
+// readFrame reads a single length-prefixed frame from r: a 4-byte
+// big-endian length followed by exactly that many bytes of payload. It
+// only depends on io.Reader so it can be exercised directly in tests
+// against readers that deliver partial or coalesced chunks, the same
+// way a real TCP socket would.
+func readFrame(r io.Reader) ([]byte, error) {
+	lengthPrefix := make([]byte, frameLengthPrefixSize)
+
+	if _, err := io.ReadFull(r, lengthPrefix); err != nil {
+		return nil, err
+	}
+
+	frameLength := binary.BigEndian.Uint32(lengthPrefix)
+
+	if frameLength == 0 || frameLength > maxFrameLength {
+		return nil, fmt.Errorf("frame length %d out of bounds", frameLength)
+	}
+
+	payload := make([]byte, frameLength)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// ListenClientReceive reads length-prefixed frames off the connection.
+// This replaces reading whatever a single Read call happened to return,
+// which broke as soon as TCP delivered a partial packet or coalesced
+// two packets together.
 func (r *Relay) ListenClientReceive(c *RelayClient) {
+	reader := bufio.NewReaderSize(c.Socket, maxFrameLength)
+
 	for {
-		buffer := make([]byte, protocol.MAX_BUFFER_LENGTH)
+		c.Socket.SetReadDeadline(time.Now().Add(clientReadTimeout))
 
-		length, err := c.Socket.Read(buffer)
+		payload, err := readFrame(reader)
 
 		if err != nil {
 			r.RemoveClient(c)
@@ -124,11 +264,7 @@ func (r *Relay) ListenClientReceive(c *RelayClient) {
 			break
 		}
 
-		if length > 0 {
-			buffer = buffer[:length]
-
-			r.HandlePacket(c, buffer)
-		}
+		r.HandlePacket(c, payload)
 	}
 }
 
@@ -137,17 +273,30 @@ func (r *Relay) ListenClientSend(c *RelayClient) {
 
 	for {
 		select {
-		case message, ok := <-c.Data:
+		case item, ok := <-c.Data:
 			if !ok {
 				// Exit for loop, execute the defer
 				return
 			}
 
-			c.Socket.Write(message)
+			metrics.LastDeliveryLatencySeconds.WithLabelValues(c.ID).Set(time.Since(item.Queued).Seconds())
+
+			c.Socket.Write(item.Payload)
 		}
 	}
 }
 
+// ServeMetrics starts the Prometheus /metrics HTTP endpoint in the
+// background so operators can scrape queue depth and drop counters
+// without affecting the TCP listener's own port.
+func (r *Relay) ServeMetrics(addr string) {
+	go func() {
+		if err := metrics.Serve(addr); err != nil {
+			logrus.WithField("error", err).Warn("Metrics server stopped")
+		}
+	}()
+}
+
 func (r *Relay) HandlePacket(client *RelayClient, buffer []byte) {
 	reader := packet.NewPacketReader(buffer)
 
@@ -165,9 +314,34 @@ func (r *Relay) HandlePacket(client *RelayClient, buffer []byte) {
 			return
 		}
 
+		if entity.IsBanned(entity.BanKindToken, string(authenticateMessage.Token)) || isRemoteAddrBanned(client) {
+			authenticateResponseMessage.Response = protocol.AuthenticateDenied
+
+			client.Socket.Write(authenticateResponseMessage.Marshal())
+
+			r.RemoveClient(client)
+			client.Socket.Close()
+
+			return
+		}
+
+		if !verifyClientCertIdentity(client.Socket, string(authenticateMessage.Token)) {
+			logrus.WithField("token", authenticateMessage.Token).Warn("Client certificate identity does not match entity token")
+
+			authenticateResponseMessage.Response = protocol.AuthenticateDenied
+
+			client.Socket.Write(authenticateResponseMessage.Marshal())
+
+			r.RemoveClient(client)
+			client.Socket.Close()
+
+			return
+		}
+
 		r.AuthenticateClient(client, authenticateMessage)
 
 		authenticateResponseMessage.Response = protocol.AuthenticateSuccess
+		authenticateResponseMessage.ProtocolVersion = protocol.NegotiateVersion(authenticateMessage.ProtocolVersion)
 
 		client.Socket.Write(authenticateResponseMessage.Marshal())
 
@@ -196,16 +370,34 @@ func (r *Relay) HandlePacket(client *RelayClient, buffer []byte) {
 }
 
 func (r *Relay) AddClient(c *RelayClient) {
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
 	r.Clients[c] = true
 }
 
 func (r *Relay) RemoveClient(c *RelayClient) {
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
 	if _, ok := r.Clients[c]; ok {
 		close(c.Data)
 		delete(r.Clients, c)
 	}
 }
 
+// isRemoteAddrBanned checks the connecting IP against the ban list,
+// stripped of its port since bans are stored by host only.
+func isRemoteAddrBanned(c *RelayClient) bool {
+	host, _, err := net.SplitHostPort(c.Socket.RemoteAddr().String())
+
+	if err != nil {
+		return false
+	}
+
+	return entity.IsBanned(entity.BanKindIP, host)
+}
+
 func (r *Relay) AuthenticateClient(c *RelayClient, packet *protocol.AuthenticateMessage) {
 	tEntity, err := entity.GetEntity(packet.Token)
 
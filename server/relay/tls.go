@@ -0,0 +1,90 @@
+package relay
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+)
+
+// TLSOptions configures the optional TLS listener. CertFile/KeyFile are
+// always required when TLS is enabled; ClientCAFile is optional and, when
+// set, turns on mutual TLS so only clients presenting a certificate
+// signed by that CA can connect at all.
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// listen opens the TCP listener, wrapping it in TLS when opts is
+// non-nil so operators can safely expose the relay across the public
+// internet between game servers and the central bot host.
+func listen(addr string, opts *TLSOptions) (net.Listener, error) {
+	if opts == nil {
+		return net.Listen("tcp", addr)
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if len(opts.ClientCAFile) != 0 {
+		caCert, err := os.ReadFile(opts.ClientCAFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tls.Listen("tcp", addr, config)
+}
+
+// verifyClientCertIdentity checks, when the connection is TLS with
+// client certificates, that the presented certificate's CN or a SAN
+// matches the entity token the client authenticated with. A mismatch
+// means someone is presenting a valid-but-wrong certificate for the
+// token they're claiming, which we treat the same as an invalid token.
+func verifyClientCertIdentity(conn net.Conn, token string) bool {
+	tlsConn, ok := conn.(*tls.Conn)
+
+	if !ok {
+		return true
+	}
+
+	state := tlsConn.ConnectionState()
+
+	if len(state.PeerCertificates) == 0 {
+		return true
+	}
+
+	cert := state.PeerCertificates[0]
+
+	if cert.Subject.CommonName == token {
+		return true
+	}
+
+	for _, name := range cert.DNSNames {
+		if name == token {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,9 @@
 package bot
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/Necroforger/dgrouter"
 	"github.com/Necroforger/dgrouter/exrouter"
 	log "github.com/sirupsen/logrus"
@@ -9,6 +12,10 @@ import (
 	"github.com/rumblefrog/source-chat-relay/src/server/helper"
 )
 
+// zombieCheckInterval mirrors Discord's own heartbeat cadence closely
+// enough to catch a missed ACK within a couple of intervals.
+const zombieCheckInterval = 45 * time.Second
+
 type DiscordBot struct {
 	Session       *discordgo.Session
 	RelayChannels []*RelayChannel
@@ -29,7 +36,14 @@ func init() {
 		log.Fatal("Unable to initiate bot session")
 	}
 
+	// Route every outbound REST call through the rate limiter so a burst
+	// of game-server events can't run the bot token into a Discord ban.
+	session.Client.Transport = &rateLimitedTransport{next: http.DefaultTransport}
+
 	session.AddHandler(ready)
+	session.AddHandler(onConnect)
+	session.AddHandler(onResumed)
+	addCommandHandler(session)
 
 	err = session.Open()
 
@@ -55,6 +69,8 @@ func ready(s *discordgo.Session, event *discordgo.Ready) {
 		Session: s,
 	}
 
+	registerCommands(s)
+
 	log.WithFields(log.Fields{
 		"Username":    event.User.Username,
 		"Session ID":  event.SessionID,
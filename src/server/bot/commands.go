@@ -0,0 +1,418 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rumblefrog/source-chat-relay/server/entity"
+	"github.com/rumblefrog/source-chat-relay/server/filter"
+	"github.com/rumblefrog/source-chat-relay/src/server/helper"
+	log "github.com/sirupsen/logrus"
+)
+
+// relayCommand is the single top-level "/relay" application command,
+// with everything else expressed as subcommands/subcommand groups so
+// Discord renders one coherent entry in the slash-command picker.
+var relayCommand = &discordgo.ApplicationCommand{
+	Name:        "relay",
+	Description: "Administer the chat relay",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "bind",
+			Description: "Bind this channel to a relay entity",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "token",
+					Description:  "Entity token",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "unbind",
+			Description: "Unbind this channel from a relay entity",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "token",
+					Description:  "Entity token",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "List entities bound to this channel",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        "ban",
+			Description: "Manage the relay ban list",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Ban a token, IP, or player name from the relay",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionString, Name: "kind", Description: "token|ip|name", Required: true},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "value", Description: "Value to ban", Required: true},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Reason", Required: false},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "duration", Description: "How long the ban lasts (e.g. 1h, 30m); omit for permanent", Required: false},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Remove a ban",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionString, Name: "kind", Description: "token|ip|name", Required: true},
+						{Type: discordgo.ApplicationCommandOptionString, Name: "value", Description: "Value to unban", Required: true},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        "filter",
+			Description: "Manage the message filter",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Add a filter pattern",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionString, Name: "pattern", Description: "Regex pattern", Required: true},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Remove a filter pattern",
+					Options: []*discordgo.ApplicationCommandOption{
+						{Type: discordgo.ApplicationCommandOptionString, Name: "pattern", Description: "Regex pattern", Required: true},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "whois",
+			Description: "Look up the entity behind a token",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "token",
+					Description:  "Entity token",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+	},
+}
+
+// registerCommands upserts the application command tree with Discord.
+// Called from the ready handler so it re-registers on every reconnect,
+// which is a cheap no-op when the definition hasn't changed.
+func registerCommands(s *discordgo.Session) {
+	if _, err := s.ApplicationCommandCreate(s.State.User.ID, "", relayCommand); err != nil {
+		log.WithField("error", err).Warn("Failed to register /relay command")
+	}
+}
+
+// addCommandHandler wires the interaction dispatcher onto the given
+// session. Called once from the package init alongside the message
+// router handler.
+func addCommandHandler(s *discordgo.Session) {
+	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			handleCommand(s, i)
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			handleAutocomplete(s, i)
+		}
+	})
+}
+
+func handleCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	if data.Name != "relay" {
+		return
+	}
+
+	if !isAuthorized(i.Member) {
+		respondEphemeral(s, i, "You are not permitted to administer the relay.")
+
+		return
+	}
+
+	opt := data.Options[0]
+
+	switch opt.Name {
+	case "bind":
+		handleBind(s, i, opt)
+	case "unbind":
+		handleUnbind(s, i, opt)
+	case "list":
+		handleList(s, i, opt)
+	case "ban":
+		handleBan(s, i, opt)
+	case "filter":
+		handleFilter(s, i, opt)
+	case "whois":
+		handleWhois(s, i, opt)
+	}
+}
+
+func handleBind(s *discordgo.Session, i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) {
+	token := opt.Options[0].StringValue()
+
+	tEntity, err := entity.GetEntity(token)
+
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("No entity found for token `%s`.", token))
+
+		return
+	}
+
+	if err := tEntity.BindChannel(i.ChannelID); err != nil {
+		respondEphemeral(s, i, "Failed to bind channel: "+err.Error())
+
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Bound `%s` to this channel.", tEntity.DisplayName))
+}
+
+func handleUnbind(s *discordgo.Session, i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) {
+	token := opt.Options[0].StringValue()
+
+	tEntity, err := entity.GetEntity(token)
+
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("No entity found for token `%s`.", token))
+
+		return
+	}
+
+	if err := tEntity.UnbindChannel(i.ChannelID); err != nil {
+		respondEphemeral(s, i, "Failed to unbind channel: "+err.Error())
+
+		return
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("Unbound `%s` from this channel.", tEntity.DisplayName))
+}
+
+func handleList(s *discordgo.Session, i *discordgo.InteractionCreate, _ *discordgo.ApplicationCommandInteractionDataOption) {
+	entities, err := entity.GetEntitiesByChannel(i.ChannelID)
+
+	if err != nil {
+		respondEphemeral(s, i, "Failed to list entities: "+err.Error())
+
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Entities bound to this channel",
+	}
+
+	for _, e := range entities {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  e.DisplayName,
+			Value: e.ID,
+		})
+	}
+
+	respondEmbed(s, i, embed)
+}
+
+func handleBan(s *discordgo.Session, i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) {
+	sub := opt.Options[0]
+
+	kind := sub.Options[0].StringValue()
+	value := sub.Options[1].StringValue()
+
+	switch sub.Name {
+	case "add":
+		reason := ""
+
+		var duration time.Duration
+
+		// reason and duration are both optional, so either may be the
+		// third option; match them by name rather than position.
+		for _, o := range sub.Options[2:] {
+			switch o.Name {
+			case "reason":
+				reason = o.StringValue()
+			case "duration":
+				d, err := time.ParseDuration(o.StringValue())
+
+				if err != nil {
+					respondEphemeral(s, i, "Invalid duration: "+err.Error())
+
+					return
+				}
+
+				duration = d
+			}
+		}
+
+		if err := entity.AddBan(kind, value, reason, i.Member.User.ID, duration); err != nil {
+			respondEphemeral(s, i, "Failed to add ban: "+err.Error())
+
+			return
+		}
+
+		if duration > 0 {
+			respondEphemeral(s, i, fmt.Sprintf("Banned %s `%s` for %s.", kind, value, duration))
+		} else {
+			respondEphemeral(s, i, fmt.Sprintf("Banned %s `%s` permanently.", kind, value))
+		}
+	case "remove":
+		if err := entity.RemoveBan(kind, value); err != nil {
+			respondEphemeral(s, i, "Failed to remove ban: "+err.Error())
+
+			return
+		}
+
+		respondEphemeral(s, i, fmt.Sprintf("Unbanned %s `%s`.", kind, value))
+	}
+}
+
+func handleFilter(s *discordgo.Session, i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) {
+	sub := opt.Options[0]
+	pattern := sub.Options[0].StringValue()
+
+	switch sub.Name {
+	case "add":
+		if err := filter.AddPattern(pattern); err != nil {
+			respondEphemeral(s, i, "Failed to add filter: "+err.Error())
+
+			return
+		}
+
+		respondEphemeral(s, i, fmt.Sprintf("Added filter pattern `%s`.", pattern))
+	case "remove":
+		if err := filter.RemovePattern(pattern); err != nil {
+			respondEphemeral(s, i, "Failed to remove filter: "+err.Error())
+
+			return
+		}
+
+		respondEphemeral(s, i, fmt.Sprintf("Removed filter pattern `%s`.", pattern))
+	}
+}
+
+func handleWhois(s *discordgo.Session, i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) {
+	token := opt.Options[0].StringValue()
+
+	tEntity, err := entity.GetEntity(token)
+
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("No entity found for token `%s`.", token))
+
+		return
+	}
+
+	respondEmbed(s, i, &discordgo.MessageEmbed{
+		Title: "Whois",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Token", Value: tEntity.ID},
+			{Name: "Display Name", Value: tEntity.DisplayName},
+		},
+	})
+}
+
+func handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	for _, opt := range data.Options[0].Options {
+		if !opt.Focused {
+			continue
+		}
+
+		matches, err := entity.SearchEntities(opt.StringValue())
+
+		if err != nil {
+			return
+		}
+
+		choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(matches))
+
+		for _, e := range matches {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  e.DisplayName,
+				Value: e.ID,
+			})
+		}
+
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{
+				Choices: choices,
+			},
+		})
+	}
+}
+
+// isAuthorized checks the invoking member against the configured
+// administrator roles/user IDs. Unconfigured installs deny by default
+// rather than leaving relay administration open to anyone.
+func isAuthorized(member *discordgo.Member) bool {
+	if member == nil {
+		return false
+	}
+
+	for _, id := range helper.Conf.Bot.AdminUserIDs {
+		if member.User.ID == id {
+			return true
+		}
+	}
+
+	for _, role := range member.Roles {
+		for _, adminRole := range helper.Conf.Bot.AdminRoleIDs {
+			if role == adminRole {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	if err != nil {
+		log.WithField("error", err).Warn("Failed to respond to interaction")
+	}
+}
+
+func respondEmbed(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	if err != nil {
+		log.WithField("error", err).Warn("Failed to respond to interaction")
+	}
+}
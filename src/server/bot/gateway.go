@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// isZombied reports whether discordgo's own gateway state shows a
+// heartbeat was sent but never acknowledged within interval — the
+// definition of a zombie connection. Session embeds a sync.RWMutex
+// guarding LastHeartbeatSent/LastHeartbeatAck, so we take the read lock
+// before touching them.
+func isZombied(s *discordgo.Session, interval time.Duration) bool {
+	s.RLock()
+	sent := s.LastHeartbeatSent
+	acked := s.LastHeartbeatAck
+	s.RUnlock()
+
+	return sent.After(acked) && time.Since(sent) > interval
+}
+
+// watchZombieConnection polls discordgo's heartbeat timestamps every
+// interval and forces a reconnect if the session stopped receiving
+// ACKs, since discordgo will otherwise happily keep writing into a dead
+// socket. It closes and reopens the session, then exits; onConnect
+// spawns a fresh watcher for the reopened connection (and for every one
+// discordgo's own auto-reconnect brings back up), so detection keeps
+// running across the connection's whole lifetime, not just its first
+// leg.
+func watchZombieConnection(s *discordgo.Session, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if isZombied(s, interval) {
+			log.Warn("Zombie gateway connection detected, forcing reconnect")
+
+			s.Close()
+
+			if err := s.Open(); err != nil {
+				log.WithField("error", err).Warn("Failed to reopen gateway connection")
+			}
+
+			return
+		}
+	}
+}
+
+// onConnect fires every time discordgo establishes the gateway
+// websocket, both the first time and after every automatic reconnect
+// (discordgo.New sets ShouldReconnectOnError: true and handles
+// RESUME/IDENTIFY itself using its own unexported session/sequence
+// state), so it's the right place to restart zombie detection for the
+// new connection.
+func onConnect(s *discordgo.Session, _ *discordgo.Connect) {
+	go watchZombieConnection(s, zombieCheckInterval)
+}
+
+func onResumed(_ *discordgo.Session, r *discordgo.Resumed) {
+	log.WithField("sequence", r.Seq).Info("Gateway session resumed")
+}
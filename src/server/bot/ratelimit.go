@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// routeBucket is a token bucket scoped to a single Discord
+// X-RateLimit-Bucket, refilled from the response headers of the most
+// recent request against that bucket.
+type routeBucket struct {
+	mu        sync.Mutex
+	remaining int
+	resetAt   time.Time
+}
+
+func (b *routeBucket) wait() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.remaining > 0 {
+		b.remaining--
+
+		return
+	}
+
+	if until := time.Until(b.resetAt); until > 0 {
+		time.Sleep(until)
+	}
+}
+
+func (b *routeBucket) update(remaining int, resetAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remaining = remaining
+	b.resetAt = resetAt
+}
+
+// restRateLimiter layers per-route buckets on top of a single global
+// bucket so a 429 with X-RateLimit-Global pauses every outbound request,
+// not just the one that tripped it.
+type restRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*routeBucket
+	// routeBuckets maps a route key (method+path) to the real
+	// X-RateLimit-Bucket Discord assigned it, once known. Discord scopes
+	// rate limits per bucket, not per route, so without this mapping
+	// every call after the first would key on the route again and never
+	// see the bucket's actual remaining/reset state.
+	routeBuckets map[string]string
+
+	globalMu    sync.RWMutex
+	globalUntil time.Time
+}
+
+var limiter = &restRateLimiter{
+	buckets:      make(map[string]*routeBucket),
+	routeBuckets: make(map[string]string),
+}
+
+func (l *restRateLimiter) bucketFor(key string) *routeBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &routeBucket{}
+		l.buckets[key] = b
+	}
+
+	return b
+}
+
+// resolve maps a route key to the real Discord bucket key for it, if one
+// has been learned from a prior response, falling back to the route key
+// itself before that.
+func (l *restRateLimiter) resolve(routeKey string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if real, ok := l.routeBuckets[routeKey]; ok {
+		return real
+	}
+
+	return routeKey
+}
+
+// Before should be called immediately before issuing a REST request for
+// the given Discord bucket key (the X-RateLimit-Bucket header from the
+// prior response, or the route itself on the first call).
+func (l *restRateLimiter) Before(bucketKey string) {
+	l.globalMu.RLock()
+	until := l.globalUntil
+	l.globalMu.RUnlock()
+
+	if wait := time.Until(until); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	l.bucketFor(bucketKey).wait()
+}
+
+// After reads the rate limit headers off a completed response and
+// updates the relevant bucket, pausing all requests globally if Discord
+// flagged this as a global rate limit. routeKey is the same method+path
+// key Before was resolved from, used to remember the route's real bucket
+// for subsequent requests.
+func (l *restRateLimiter) After(routeKey string, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	if resp.Header.Get("X-RateLimit-Global") == "true" {
+		retryAfter, _ := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+
+		l.globalMu.Lock()
+		l.globalUntil = time.Now().Add(time.Duration(retryAfter * float64(time.Second)))
+		l.globalMu.Unlock()
+
+		log.WithField("retry_after", retryAfter).Warn("Hit Discord global rate limit, pausing all requests")
+
+		return
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+
+	if err != nil {
+		return
+	}
+
+	resetAfter, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset-After"), 64)
+
+	if err != nil {
+		return
+	}
+
+	key := resp.Header.Get("X-RateLimit-Bucket")
+
+	if len(key) == 0 {
+		key = routeKey
+	} else {
+		l.mu.Lock()
+		l.routeBuckets[routeKey] = key
+		l.mu.Unlock()
+	}
+
+	l.bucketFor(key).update(remaining, time.Now().Add(time.Duration(resetAfter*float64(time.Second))))
+}
+
+// rateLimitedTransport is an http.RoundTripper that runs every request
+// discordgo makes through the limiter above. Swapped into the
+// session's http.Client so REST calls can't bypass it.
+type rateLimitedTransport struct {
+	next http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Before a bucket's first response, Discord hasn't told us its real
+	// X-RateLimit-Bucket yet, so key on the route itself until limiter
+	// learns the real bucket for it.
+	routeKey := req.Method + " " + req.URL.Path
+
+	limiter.Before(limiter.resolve(routeKey))
+
+	resp, err := t.next.RoundTrip(req)
+
+	if err != nil {
+		return resp, err
+	}
+
+	limiter.After(routeKey, resp)
+
+	return resp, nil
+}
@@ -0,0 +1,23 @@
+package helper
+
+// BotConfig holds the Discord bot settings read out of the relay's
+// config file.
+type BotConfig struct {
+	Token string
+
+	// AdminUserIDs and AdminRoleIDs gate the /relay slash-command
+	// surface: a member passes isAuthorized if their user ID or any of
+	// their roles appears in either list. Left empty, administration
+	// commands are unreachable rather than open to anyone.
+	AdminUserIDs []string
+	AdminRoleIDs []string
+}
+
+// Config is the root of the relay's configuration file.
+type Config struct {
+	Bot BotConfig
+}
+
+// Conf is the process-wide parsed configuration, populated before the
+// bot and relay packages initialize.
+var Conf Config